@@ -10,4 +10,29 @@ type Runtime interface {
 	Delete(*Container) error
 	Exec(*Container, *Process) (ProcessDelegate, error)
 	Load(id string) (ProcessDelegate, error)
+	Checkpoint(*Container, CheckpointOpts) error
+	Restore(*Container, RestoreOpts) (ProcessDelegate, error)
+}
+
+// CheckpointOpts carries the CRIU flags a runc/CRIU-compatible runtime's
+// checkpoint subcommand accepts.
+type CheckpointOpts struct {
+	ImagePath                string
+	WorkDir                  string
+	ParentPath               string
+	AllowOpenTCP             bool
+	AllowExternalUnixSockets bool
+	AllowTerminal            bool
+	FileLocks                bool
+	EmptyNamespaces          []string
+	CgroupsMode              string
+	LeaveRunning             bool
+	PreDump                  bool
+}
+
+// RestoreOpts carries what a Runtime needs to bring a container back from
+// a previous Checkpoint.
+type RestoreOpts struct {
+	// ImagePath is the CRIU image directory a prior Checkpoint wrote to.
+	ImagePath string
 }
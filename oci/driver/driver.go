@@ -0,0 +1,144 @@
+// Package driver abstracts over the command line surface of an OCI
+// compliant runtime binary (runc, crun, runsc, ...). Different runtimes lay
+// out their argv differently and differ in what checkpoint/restore and
+// stats support they offer; a Driver hides those differences behind one
+// typed interface so the shim package can work with any of them.
+package driver
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+)
+
+// CreateOpts carries what a runtime needs to create a container.
+type CreateOpts struct {
+	ID       string
+	Bundle   string
+	PidFile  string
+	Terminal bool
+	Console  string
+}
+
+// ExecOpts carries what a runtime needs to exec a new process inside an
+// already running container. SpecPath is the path to a json encoded
+// specs.Process.
+type ExecOpts struct {
+	ID       string
+	SpecPath string
+	PidFile  string
+	Terminal bool
+	Console  string
+	Detach   bool
+}
+
+// State is a runtime-reported snapshot of a container.
+type State struct {
+	ID     string
+	Pid    int
+	Status string
+	Bundle string
+}
+
+// Stats is the raw, driver specific stats payload. Callers that need a
+// normalized view (e.g. shim.Stats) read cgroups directly rather than go
+// through here; this exists for drivers, like runsc, that can report
+// their own sandboxed view of resource usage.
+type Stats struct {
+	Raw []byte
+}
+
+// Event is a single line of output from a runtime's events stream.
+type Event struct {
+	Type string
+	ID   string
+	Pid  int
+	Data []byte
+}
+
+// CheckpointOpts carries the CRIU flags a runtime's checkpoint subcommand
+// accepts.
+type CheckpointOpts struct {
+	ImagePath                string
+	WorkDir                  string
+	ParentPath               string
+	AllowOpenTCP             bool
+	AllowExternalUnixSockets bool
+	AllowTerminal            bool
+	FileLocks                bool
+	EmptyNamespaces          []string
+	CgroupsMode              string
+	LeaveRunning             bool
+	PreDump                  bool
+}
+
+// RestoreOpts carries the flags a runtime's restore subcommand accepts.
+type RestoreOpts struct {
+	ImagePath   string
+	WorkDir     string
+	Bundle      string
+	PidFile     string
+	Detach      bool
+	NoPivotRoot bool
+}
+
+// Driver is the set of operations the shim needs from an OCI runtime. Each
+// implementation is responsible for translating these calls into whatever
+// argv and output format its runtime binary actually speaks.
+type Driver interface {
+	Create(opts CreateOpts) error
+	Start(id string) error
+	Exec(opts ExecOpts) (int, error)
+	Kill(id string, signal uint32, all bool) error
+	Delete(id string) error
+	State(id string) (*State, error)
+	Stats(id string) (*Stats, error)
+	Events(id string) (<-chan Event, error)
+	Checkpoint(id string, opts CheckpointOpts) error
+	Restore(id string, opts RestoreOpts) error
+	Ps(id string) ([]int, error)
+	// Cmd builds a raw, driver-prefixed command for operations that have
+	// no typed method above.
+	Cmd(args ...string) *exec.Cmd
+	// Close releases any background resources the driver started for
+	// this container (e.g. runsc's log tailer). It is safe to call more
+	// than once.
+	Close() error
+}
+
+// Factory constructs a Driver for a runtime binary named name (e.g.
+// "runc", "runsc"), invoked with the given extra args, configured with the
+// free-form key/value config recorded in shim.Opts.RuntimeConfig.
+type Factory func(name string, args []string, config map[string]string) (Driver, error)
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Factory{}
+)
+
+// Register adds a Factory to the driver registry under name. Runtime
+// packages call this from an init function.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[name] = f
+}
+
+// New looks up the Factory registered for name and uses it to construct a
+// Driver. Runtimes with no registered factory (anything argv-compatible
+// with runc other than runc and crun, which are registered by name) fall
+// back to the runc driver, which preserves the shim's original behavior of
+// shelling out by binary name alone.
+func New(name string, args []string, config map[string]string) (Driver, error) {
+	mu.Lock()
+	f, ok := registry[name]
+	mu.Unlock()
+	if !ok {
+		f = newRunc
+	}
+	d, err := f(name, args, config)
+	if err != nil {
+		return nil, fmt.Errorf("driver: %s: %w", name, err)
+	}
+	return d, nil
+}
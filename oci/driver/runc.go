@@ -0,0 +1,196 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+func init() {
+	Register("runc", newRunc)
+	// crun speaks the same argv and JSON output as runc.
+	Register("crun", newRunc)
+}
+
+// runcDriver is the default Driver: it shells out to the named binary
+// using the plain runc argv layout. It is also used as the fallback for
+// any runtime name with no registered Factory.
+type runcDriver struct {
+	name string
+	args []string
+}
+
+func newRunc(name string, args []string, config map[string]string) (Driver, error) {
+	return &runcDriver{name: name, args: args}, nil
+}
+
+func (r *runcDriver) Cmd(args ...string) *exec.Cmd {
+	return exec.Command(r.name, append(r.args, args...)...)
+}
+
+func (r *runcDriver) Create(opts CreateOpts) error {
+	args := []string{"create", "--bundle", opts.Bundle, "--pid-file", opts.PidFile}
+	if opts.Terminal && opts.Console != "" {
+		args = append(args, "--console-socket", opts.Console)
+	}
+	args = append(args, opts.ID)
+	return run(r.Cmd(args...))
+}
+
+func (r *runcDriver) Start(id string) error {
+	return run(r.Cmd("start", id))
+}
+
+func (r *runcDriver) Exec(opts ExecOpts) (int, error) {
+	args := []string{"exec", "--pid-file", opts.PidFile, "--process", opts.SpecPath}
+	if opts.Detach {
+		args = append(args, "-d")
+	}
+	if opts.Terminal && opts.Console != "" {
+		args = append(args, "--console-socket", opts.Console)
+	}
+	args = append(args, opts.ID)
+	if err := run(r.Cmd(args...)); err != nil {
+		return -1, err
+	}
+	return readPid(opts.PidFile)
+}
+
+func (r *runcDriver) Kill(id string, signal uint32, all bool) error {
+	args := []string{"kill"}
+	if all {
+		args = append(args, "--all")
+	}
+	args = append(args, id, strconv.Itoa(int(signal)))
+	return run(r.Cmd(args...))
+}
+
+func (r *runcDriver) Delete(id string) error {
+	return run(r.Cmd("delete", id))
+}
+
+func (r *runcDriver) State(id string) (*State, error) {
+	out, err := r.Cmd("state", id).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q", err, out)
+	}
+	var raw struct {
+		ID     string `json:"id"`
+		Pid    int    `json:"pid"`
+		Status string `json:"status"`
+		Bundle string `json:"bundle"`
+	}
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return nil, err
+	}
+	return &State{ID: raw.ID, Pid: raw.Pid, Status: raw.Status, Bundle: raw.Bundle}, nil
+}
+
+func (r *runcDriver) Stats(id string) (*Stats, error) {
+	return nil, fmt.Errorf("driver: runc does not support stats, read the container's cgroup instead")
+}
+
+func (r *runcDriver) Events(id string) (<-chan Event, error) {
+	cmd := r.Cmd("events", id)
+	return streamEvents(cmd, id)
+}
+
+func (r *runcDriver) Checkpoint(id string, opts CheckpointOpts) error {
+	args := append([]string{"checkpoint"}, checkpointArgs(opts)...)
+	args = append(args, id)
+	return run(r.Cmd(args...))
+}
+
+func (r *runcDriver) Restore(id string, opts RestoreOpts) error {
+	args := append([]string{"restore"}, restoreArgs(opts)...)
+	args = append(args, id)
+	return run(r.Cmd(args...))
+}
+
+func (r *runcDriver) Ps(id string) ([]int, error) {
+	out, err := r.Cmd("ps", "--format", "json", id).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q", err, out)
+	}
+	var pids []int
+	if err := json.Unmarshal(out, &pids); err != nil {
+		return nil, err
+	}
+	return pids, nil
+}
+
+// Close is a no-op: runcDriver starts no background goroutines of its own.
+func (r *runcDriver) Close() error {
+	return nil
+}
+
+func run(cmd *exec.Cmd) error {
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %q", err, out)
+	}
+	return nil
+}
+
+// checkpointArgs and restoreArgs are shared by the runc and runsc drivers
+// since both runtimes accept the same CRIU flag names.
+func checkpointArgs(opts CheckpointOpts) []string {
+	var args []string
+	if opts.ImagePath != "" {
+		args = append(args, "--image-path", opts.ImagePath)
+	}
+	if opts.WorkDir != "" {
+		args = append(args, "--work-path", opts.WorkDir)
+	}
+	if opts.ParentPath != "" {
+		args = append(args, "--parent-path", opts.ParentPath)
+	}
+	if opts.AllowOpenTCP {
+		args = append(args, "--tcp-established")
+	}
+	if opts.AllowExternalUnixSockets {
+		args = append(args, "--ext-unix-sk")
+	}
+	if opts.AllowTerminal {
+		args = append(args, "--shell-job")
+	}
+	if opts.FileLocks {
+		args = append(args, "--file-locks")
+	}
+	for _, ns := range opts.EmptyNamespaces {
+		args = append(args, "--empty-ns", ns)
+	}
+	if opts.CgroupsMode != "" {
+		args = append(args, "--manage-cgroups-mode", opts.CgroupsMode)
+	}
+	if opts.LeaveRunning {
+		args = append(args, "--leave-running")
+	}
+	if opts.PreDump {
+		args = append(args, "--pre-dump")
+	}
+	return args
+}
+
+func restoreArgs(opts RestoreOpts) []string {
+	var args []string
+	if opts.ImagePath != "" {
+		args = append(args, "--image-path", opts.ImagePath)
+	}
+	if opts.WorkDir != "" {
+		args = append(args, "--work-path", opts.WorkDir)
+	}
+	if opts.Bundle != "" {
+		args = append(args, "--bundle", opts.Bundle)
+	}
+	if opts.PidFile != "" {
+		args = append(args, "--pid-file", opts.PidFile)
+	}
+	if opts.Detach {
+		args = append(args, "-d")
+	}
+	if opts.NoPivotRoot {
+		args = append(args, "--no-pivot")
+	}
+	return args
+}
@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts CheckpointOpts
+		want []string
+	}{
+		{
+			name: "empty",
+			opts: CheckpointOpts{},
+			want: nil,
+		},
+		{
+			name: "every flag",
+			opts: CheckpointOpts{
+				ImagePath:                "/image",
+				WorkDir:                  "/work",
+				ParentPath:               "/parent",
+				AllowOpenTCP:             true,
+				AllowExternalUnixSockets: true,
+				AllowTerminal:            true,
+				FileLocks:                true,
+				EmptyNamespaces:          []string{"network", "pid"},
+				CgroupsMode:              "soft",
+				LeaveRunning:             true,
+				PreDump:                  true,
+			},
+			want: []string{
+				"--image-path", "/image",
+				"--work-path", "/work",
+				"--parent-path", "/parent",
+				"--tcp-established",
+				"--ext-unix-sk",
+				"--shell-job",
+				"--file-locks",
+				"--empty-ns", "network",
+				"--empty-ns", "pid",
+				"--manage-cgroups-mode", "soft",
+				"--leave-running",
+				"--pre-dump",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := checkpointArgs(tc.opts); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("checkpointArgs(%+v) = %v, want %v", tc.opts, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRestoreArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts RestoreOpts
+		want []string
+	}{
+		{
+			name: "empty",
+			opts: RestoreOpts{},
+			want: nil,
+		},
+		{
+			name: "every flag",
+			opts: RestoreOpts{
+				ImagePath:   "/image",
+				WorkDir:     "/work",
+				Bundle:      "/bundle",
+				PidFile:     "/pid",
+				Detach:      true,
+				NoPivotRoot: true,
+			},
+			want: []string{
+				"--image-path", "/image",
+				"--work-path", "/work",
+				"--bundle", "/bundle",
+				"--pid-file", "/pid",
+				"-d",
+				"--no-pivot",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := restoreArgs(tc.opts); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("restoreArgs(%+v) = %v, want %v", tc.opts, got, tc.want)
+			}
+		})
+	}
+}
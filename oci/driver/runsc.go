@@ -0,0 +1,264 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func init() {
+	Register("runsc", newRunsc)
+}
+
+// runscDriver drives gVisor's runsc. It speaks the same subcommand surface
+// as runc (create/start/exec/kill/delete/state/ps/events) but needs a set
+// of global flags ahead of the subcommand, and additional arbitrary
+// --key=value flags from Config for anything this package doesn't model
+// as a typed field (e.g. --network, --file-access, --overlay).
+type runscDriver struct {
+	name         string
+	args         []string
+	root         string
+	log          string
+	logFormat    string
+	platform     string
+	pdeathSignal int
+	setpgid      bool
+	config       map[string]string
+
+	// tailOnce and tailDone bound the log tailer to a single goroutine
+	// for the lifetime of the driver, rather than one per Create (every
+	// container sharing this driver shares the same runsc --log file, so
+	// there is never a reason to tail it more than once), and let Close
+	// stop it instead of leaking it forever.
+	tailOnce sync.Once
+	tailDone chan struct{}
+}
+
+func newRunsc(name string, args []string, config map[string]string) (Driver, error) {
+	d := &runscDriver{
+		name:      name,
+		args:      args,
+		root:      config["root"],
+		log:       config["log"],
+		logFormat: config["log-format"],
+		platform:  config["platform"],
+		config:    config,
+		tailDone:  make(chan struct{}),
+	}
+	if v, ok := config["pdeath-signal"]; ok {
+		sig, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("runsc: invalid pdeath-signal %q: %w", v, err)
+		}
+		d.pdeathSignal = sig
+	}
+	if v, ok := config["setpgid"]; ok {
+		d.setpgid = v == "true"
+	}
+	return d, nil
+}
+
+// knownConfigKeys are surfaced as typed fields above and must not also be
+// passed through as --key=value flags.
+var knownConfigKeys = map[string]bool{
+	"root": true, "log": true, "log-format": true, "platform": true,
+	"pdeath-signal": true, "setpgid": true,
+}
+
+func (r *runscDriver) globalArgs() []string {
+	args := make([]string, 0, len(r.config)+4)
+	if r.root != "" {
+		args = append(args, "--root", r.root)
+	}
+	if r.log != "" {
+		args = append(args, "--log", r.log)
+	}
+	if r.logFormat != "" {
+		args = append(args, "--log-format", r.logFormat)
+	}
+	if r.platform != "" {
+		args = append(args, "--platform", r.platform)
+	}
+	keys := make([]string, 0, len(r.config))
+	for k := range r.config {
+		if knownConfigKeys[k] {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, fmt.Sprintf("--%s=%s", k, r.config[k]))
+	}
+	return args
+}
+
+func (r *runscDriver) Cmd(args ...string) *exec.Cmd {
+	full := append(append([]string{}, r.args...), r.globalArgs()...)
+	full = append(full, args...)
+	cmd := exec.Command(r.name, full...)
+	if r.setpgid || r.pdeathSignal != 0 {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setpgid:   r.setpgid,
+			Pdeathsig: syscall.Signal(r.pdeathSignal),
+		}
+	}
+	return cmd
+}
+
+func (r *runscDriver) Create(opts CreateOpts) error {
+	args := []string{"create", "--bundle", opts.Bundle, "--pid-file", opts.PidFile}
+	if opts.Terminal && opts.Console != "" {
+		args = append(args, "--console-socket", opts.Console)
+	}
+	args = append(args, opts.ID)
+	if err := run(r.Cmd(args...)); err != nil {
+		return err
+	}
+	if r.log != "" {
+		r.tailOnce.Do(func() { go tailLog(r.log, r.tailDone) })
+	}
+	return nil
+}
+
+// Close stops this driver's log tailer, if one was started. It is safe to
+// call even if no container was ever created.
+func (r *runscDriver) Close() error {
+	select {
+	case <-r.tailDone:
+	default:
+		close(r.tailDone)
+	}
+	return nil
+}
+
+func (r *runscDriver) Start(id string) error {
+	return run(r.Cmd("start", id))
+}
+
+func (r *runscDriver) Exec(opts ExecOpts) (int, error) {
+	args := []string{"exec", "--pid-file", opts.PidFile, "--process", opts.SpecPath}
+	if opts.Detach {
+		args = append(args, "-d")
+	}
+	if opts.Terminal && opts.Console != "" {
+		args = append(args, "--console-socket", opts.Console)
+	}
+	args = append(args, opts.ID)
+	if err := run(r.Cmd(args...)); err != nil {
+		return -1, err
+	}
+	return readPid(opts.PidFile)
+}
+
+func (r *runscDriver) Kill(id string, signal uint32, all bool) error {
+	args := []string{"kill"}
+	if all {
+		args = append(args, "--all")
+	}
+	args = append(args, id, strconv.Itoa(int(signal)))
+	return run(r.Cmd(args...))
+}
+
+func (r *runscDriver) Delete(id string) error {
+	return run(r.Cmd("delete", id))
+}
+
+func (r *runscDriver) State(id string) (*State, error) {
+	out, err := r.Cmd("state", "--format=json", id).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q", err, out)
+	}
+	var raw struct {
+		ID     string `json:"id"`
+		Pid    int    `json:"pid"`
+		Status string `json:"status"`
+		Bundle string `json:"bundle"`
+	}
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return nil, err
+	}
+	return &State{ID: raw.ID, Pid: raw.Pid, Status: raw.Status, Bundle: raw.Bundle}, nil
+}
+
+// Stats uses runsc's own `events --stats` snapshot, which reports
+// gVisor's view of the sandboxed container's resource usage rather than
+// the host cgroup's.
+func (r *runscDriver) Stats(id string) (*Stats, error) {
+	out, err := r.Cmd("events", "--stats", id).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q", err, out)
+	}
+	return &Stats{Raw: out}, nil
+}
+
+func (r *runscDriver) Events(id string) (<-chan Event, error) {
+	cmd := r.Cmd("events", id)
+	return streamEvents(cmd, id)
+}
+
+func (r *runscDriver) Checkpoint(id string, opts CheckpointOpts) error {
+	args := append([]string{"checkpoint"}, checkpointArgs(opts)...)
+	args = append(args, id)
+	return run(r.Cmd(args...))
+}
+
+func (r *runscDriver) Restore(id string, opts RestoreOpts) error {
+	args := append([]string{"restore"}, restoreArgs(opts)...)
+	args = append(args, id)
+	return run(r.Cmd(args...))
+}
+
+func (r *runscDriver) Ps(id string) ([]int, error) {
+	out, err := r.Cmd("ps", "--format", "json", id).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q", err, out)
+	}
+	var pids []int
+	if err := json.Unmarshal(out, &pids); err != nil {
+		return nil, err
+	}
+	return pids, nil
+}
+
+// tailLog forwards the lines of runsc's own log file, starting from the
+// beginning of the file, to this process's stderr so they show up
+// alongside the shim's own logs instead of being silently dropped in
+// whatever directory runsc was told to write them to. It then follows the
+// file the way `tail -f` does, since runsc keeps
+// the log open and appends to it for the lifetime of the sandbox, until
+// done is closed (by Close).
+func tailLog(path string, done <-chan struct{}) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		line, err := r.ReadString('\n')
+		if line != "" {
+			fmt.Fprint(os.Stderr, line)
+		}
+		if err != nil {
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+}
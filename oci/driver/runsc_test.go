@@ -0,0 +1,44 @@
+package driver
+
+import "testing"
+
+func TestRunscGlobalArgs(t *testing.T) {
+	r := &runscDriver{
+		root:      "/run/runsc",
+		log:       "/var/log/runsc.log",
+		logFormat: "json",
+		platform:  "kvm",
+		config: map[string]string{
+			"root":        "/run/runsc",
+			"log":         "/var/log/runsc.log",
+			"log-format":  "json",
+			"platform":    "kvm",
+			"network":     "none",
+			"file-access": "exclusive",
+		},
+	}
+	want := []string{
+		"--root", "/run/runsc",
+		"--log", "/var/log/runsc.log",
+		"--log-format", "json",
+		"--platform", "kvm",
+		"--file-access=exclusive",
+		"--network=none",
+	}
+	got := r.globalArgs()
+	if len(got) != len(want) {
+		t.Fatalf("globalArgs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("globalArgs()[%d] = %q, want %q (full: %v)", i, got[i], want[i], got)
+		}
+	}
+}
+
+func TestRunscGlobalArgsEmpty(t *testing.T) {
+	r := &runscDriver{}
+	if got := r.globalArgs(); len(got) != 0 {
+		t.Fatalf("globalArgs() = %v, want empty", got)
+	}
+}
@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+)
+
+// readPid reads a runtime-written pid file, as left behind by create/exec
+// --pid-file.
+func readPid(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return -1, err
+	}
+	var pid int
+	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
+		return -1, err
+	}
+	return pid, nil
+}
+
+// streamEvents runs cmd, which is expected to be a long running `events`
+// invocation that writes one JSON event per line to stdout, and forwards
+// each line on the returned channel until the process exits, at which
+// point the channel is closed. The process is not stopped any other way.
+func streamEvents(cmd *exec.Cmd, id string) (<-chan Event, error) {
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	events := make(chan Event, 16)
+	go func() {
+		defer close(events)
+		defer cmd.Wait()
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			events <- Event{Type: "stats", ID: id, Data: append([]byte(nil), scanner.Bytes()...)}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,87 @@
+// Package oci adapts an OCI compliant runtime binary (runc, crun, runsc,
+// ...) to the shim package. It used to be no more than a thin wrapper
+// around exec.Command; it now selects a driver.Driver for the configured
+// runtime name so callers that need runtime-specific behavior (gVisor's
+// checkpoint/restore and stats support, for instance) can get at it via
+// Driver, while callers that just want to shell out keep using Command.
+package oci
+
+import (
+	"os/exec"
+
+	"github.com/docker/containerd/oci/driver"
+)
+
+// Opts configures the OCI runtime a Shim execs containers with.
+type Opts struct {
+	// Name is the runtime binary, e.g. "runc" or "runsc".
+	Name string
+	// Args are extra arguments passed ahead of every subcommand.
+	Args []string
+	// RuntimeConfig carries driver-specific settings, e.g. runsc's
+	// "root", "log", "log-format" and "platform", plus arbitrary
+	// --key=value flags for anything this module doesn't model as a
+	// typed field.
+	RuntimeConfig map[string]string
+}
+
+// OCIRuntime is the shim's handle on the configured OCI runtime.
+type OCIRuntime struct {
+	name   string
+	args   []string
+	config map[string]string
+	driver driver.Driver
+}
+
+// New builds an OCIRuntime, selecting the driver.Driver registered for
+// opts.Name, falling back to the runc driver for any runtime this module
+// does not have a dedicated driver for.
+func New(opts Opts) (*OCIRuntime, error) {
+	d, err := driver.New(opts.Name, opts.Args, opts.RuntimeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &OCIRuntime{
+		name:   opts.Name,
+		args:   opts.Args,
+		config: opts.RuntimeConfig,
+		driver: d,
+	}, nil
+}
+
+// Name returns the runtime binary name the OCIRuntime was created with.
+func (r *OCIRuntime) Name() string {
+	return r.name
+}
+
+// Args returns the extra arguments the OCIRuntime was created with.
+func (r *OCIRuntime) Args() []string {
+	return r.args
+}
+
+// RuntimeConfig returns the driver-specific settings the OCIRuntime was
+// created with.
+func (r *OCIRuntime) RuntimeConfig() map[string]string {
+	return r.config
+}
+
+// Driver returns the runtime-specific driver.Driver backing this
+// OCIRuntime, for callers that need checkpoint/restore, stats or events.
+func (r *OCIRuntime) Driver() driver.Driver {
+	return r.driver
+}
+
+// Close releases any background resources the underlying driver started
+// (e.g. runsc's log tailer). It is safe to call more than once.
+func (r *OCIRuntime) Close() error {
+	return r.driver.Close()
+}
+
+// Command builds a raw command for the runtime binary, prefixed with
+// whatever global flags the driver needs (e.g. runsc's --root/--log).
+// Most callers should prefer Driver() for anything the Driver interface
+// models; Command remains for the handful of call sites (the legacy
+// Shim.Start, Shim.Exec and Shim.Update) that build their own argv.
+func (r *OCIRuntime) Command(args ...string) *exec.Cmd {
+	return r.driver.Cmd(args...)
+}
@@ -0,0 +1,115 @@
+package shim
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/containerd/oci/driver"
+)
+
+// CheckpointOpts carries the CRIU flags a runc/CRIU-compatible OCI runtime's
+// checkpoint subcommand accepts. It mirrors driver.CheckpointOpts one level
+// up so callers of this package never need to reach into oci/driver
+// themselves.
+type CheckpointOpts struct {
+	ImagePath                string
+	WorkDir                  string
+	ParentPath               string
+	AllowOpenTCP             bool
+	AllowExternalUnixSockets bool
+	AllowTerminal            bool
+	FileLocks                bool
+	EmptyNamespaces          []string
+	CgroupsMode              string
+	LeaveRunning             bool
+	PreDump                  bool
+}
+
+// RestoreOpts carries what Restore needs to bring a container back from a
+// previous Checkpoint.
+type RestoreOpts struct {
+	// ImagePath is the CRIU image directory a prior Checkpoint wrote to.
+	ImagePath string
+}
+
+// Checkpoint dumps id's state to opts.ImagePath via the OCI runtime's
+// checkpoint subcommand, records the image path in state.json so Load (and
+// a later Restore) can find it, and publishes a checkpointed event. id is
+// the OCI runtime's container id.
+func (s *Shim) Checkpoint(id string, opts CheckpointOpts) error {
+	if err := s.runtime.Driver().Checkpoint(id, driver.CheckpointOpts{
+		ImagePath:                opts.ImagePath,
+		WorkDir:                  opts.WorkDir,
+		ParentPath:               opts.ParentPath,
+		AllowOpenTCP:             opts.AllowOpenTCP,
+		AllowExternalUnixSockets: opts.AllowExternalUnixSockets,
+		AllowTerminal:            opts.AllowTerminal,
+		FileLocks:                opts.FileLocks,
+		EmptyNamespaces:          opts.EmptyNamespaces,
+		CgroupsMode:              opts.CgroupsMode,
+		LeaveRunning:             opts.LeaveRunning,
+		PreDump:                  opts.PreDump,
+	}); err != nil {
+		return err
+	}
+	s.checkpoint = opts.ImagePath
+	if err := s.saveState(); err != nil {
+		return err
+	}
+	s.publish(Event{Kind: EventCheckpointed, ContainerID: id})
+	return nil
+}
+
+// Restore brings id's container back from a previous Checkpoint via the
+// OCI runtime driver's restore subcommand. It is CreateTask's counterpart
+// for a restored rather than freshly created container: same init
+// directory layout, same processes["init"] registration, same cgroup
+// discovery and exit/OOM watchers, so the rest of this package (and the
+// shim v2 task API built on it) can't tell the two apart afterward.
+func (s *Shim) Restore(id, bundle string, opts RestoreOpts) (int, error) {
+	s.pmu.Lock()
+	_, exists := s.processes["init"]
+	s.pmu.Unlock()
+	if exists {
+		return -1, fmt.Errorf("shim: container %s already created", id)
+	}
+
+	s.containerID = id
+	s.bundle = bundle
+	s.checkpoint = opts.ImagePath
+
+	dir := filepath.Join(s.root, "init")
+	if err := os.MkdirAll(dir, 0711); err != nil {
+		return -1, err
+	}
+	pidPath := filepath.Join(dir, "pid")
+	if err := s.runtime.Driver().Restore(id, driver.RestoreOpts{
+		ImagePath: opts.ImagePath,
+		Bundle:    bundle,
+		PidFile:   pidPath,
+	}); err != nil {
+		return -1, err
+	}
+	pid, err := readPidFile(pidPath)
+	if err != nil {
+		return -1, err
+	}
+
+	p := newContainerProcess(dir, pid)
+	s.pmu.Lock()
+	s.processes["init"] = p
+	s.pmu.Unlock()
+
+	if path, err := discoverCgroupPath(pid); err == nil {
+		s.cgroup = path
+	}
+	if err := s.saveState(); err != nil {
+		return -1, err
+	}
+
+	s.publish(Event{Kind: EventStart, ContainerID: id, Pid: pid})
+	go s.watchContainerExit(id, p)
+	go s.watchOOM(id, s.cgroupOrFallback(id))
+	return pid, nil
+}
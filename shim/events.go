@@ -0,0 +1,225 @@
+package shim
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind enumerates the container lifecycle transitions a Shim
+// publishes to its subscribers.
+type EventKind string
+
+const (
+	EventCreate       EventKind = "create"
+	EventStart        EventKind = "start"
+	EventExecAdded    EventKind = "exec-added"
+	EventExit         EventKind = "exit"
+	EventOOM          EventKind = "oom"
+	EventPaused       EventKind = "paused"
+	EventResumed      EventKind = "resumed"
+	EventCheckpointed EventKind = "checkpointed"
+)
+
+// Event is a single container lifecycle transition. Shim appends every
+// Event it publishes to eventsLogName under its root, in addition to
+// fanning it out to Subscribe callers, so a daemon that restarts mid
+// stream can replay what it missed the same way libcontainerd replays
+// containerd's own event log.
+type Event struct {
+	Kind        EventKind `json:"kind"`
+	ContainerID string    `json:"containerID"`
+	ProcessID   string    `json:"processID,omitempty"`
+	Pid         int       `json:"pid,omitempty"`
+	ExitStatus  uint32    `json:"exitStatus,omitempty"`
+	ExitedAt    time.Time `json:"exitedAt,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// eventsLogName is the file Events are appended to under a Shim's root.
+const eventsLogName = "events.log"
+
+// subscriber is one Subscribe call's private view onto the event stream.
+type subscriber struct {
+	ch   chan Event
+	once sync.Once
+}
+
+// initEvents opens (creating if necessary) this Shim's events.log for
+// appending and prepares its subscriber bookkeeping. It is called from
+// both New and Load, since a daemon can Subscribe after either.
+func (s *Shim) initEvents() error {
+	f, err := os.OpenFile(filepath.Join(s.root, eventsLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.eventsLog = f
+	s.subscribers = make(map[*subscriber]struct{})
+	return nil
+}
+
+// Subscribe returns a channel of every Event published for this Shim from
+// this point on, and a cancel func that unregisters it; callers must
+// invoke cancel once they are done to release the subscriber, or cancel
+// ctx to have it released for them. The channel is buffered; a subscriber
+// that falls too far behind has its oldest unread event dropped rather
+// than blocking publish on every other subscriber.
+func (s *Shim) Subscribe(ctx context.Context) (<-chan Event, func()) {
+	sub := &subscriber{ch: make(chan Event, 128)}
+	s.emu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.emu.Unlock()
+
+	cancel := func() {
+		s.emu.Lock()
+		delete(s.subscribers, sub)
+		s.emu.Unlock()
+		sub.once.Do(func() { close(sub.ch) })
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return sub.ch, cancel
+}
+
+// ReplayEvents reads every Event previously appended to this Shim's
+// events.log, in publish order, so a daemon that restarted can catch up on
+// whatever it missed before calling Subscribe for what happens next.
+func (s *Shim) ReplayEvents() ([]Event, error) {
+	f, err := os.Open(filepath.Join(s.root, eventsLogName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// publish stamps e with the current time, appends it to events.log and
+// fans it out to every live subscriber.
+func (s *Shim) publish(e Event) {
+	e.Timestamp = time.Now()
+
+	s.emu.Lock()
+	if s.eventsLog != nil {
+		if data, err := json.Marshal(e); err == nil {
+			s.eventsLog.Write(append(data, '\n'))
+		}
+	}
+	subs := make([]*subscriber, 0, len(s.subscribers))
+	for sub := range s.subscribers {
+		subs = append(subs, sub)
+	}
+	s.emu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- e:
+		default:
+			// the subscriber is behind; drop its oldest event to make
+			// room rather than block publish for everyone else.
+			select {
+			case <-sub.ch:
+			default:
+			}
+			select {
+			case sub.ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+// cgroupPath returns the memory cgroup a container with the given id runs
+// in. OCI runtimes do not report this back to their caller, so it is
+// derived the same way runc's own hooks lay it out: a directory named
+// after the container id under the memory hierarchy.
+func cgroupPath(id string) string {
+	return filepath.Join("/sys/fs/cgroup/memory", id)
+}
+
+// oomControlPath picks the file the kernel exposes OOM kill counts
+// through for cgroup, preferring the cgroup v2 unified hierarchy's
+// memory.events and falling back to v1's memory.oom_control. cgroup is
+// either an absolute path under /sys/fs/cgroup (cgroupPath) or a path
+// relative to the hierarchy's mount point, as discoverCgroupPath returns.
+func oomControlPath(cgroup string) string {
+	if !strings.HasPrefix(cgroup, "/sys/fs/cgroup/") {
+		path := filepath.Join("/sys/fs/cgroup", cgroup, "memory.events")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		return filepath.Join("/sys/fs/cgroup/memory", cgroup, "memory.oom_control")
+	}
+	path := filepath.Join(cgroup, "memory.events")
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(cgroup, "memory.oom_control")
+	}
+	return path
+}
+
+// watchOOM polls id's memory cgroup for OOM kills and publishes an
+// EventOOM each time the kill counter advances, until id's init process is
+// gone. There is no vendored cgroups package in this module to register
+// an eventfd against memory.oom_control the way runc's own notifier does,
+// so this falls back to polling, the same tradeoff oci/driver/runsc.go's
+// tailLog makes for its own log file.
+func (s *Shim) watchOOM(id, cgroup string) {
+	path := oomControlPath(cgroup)
+	var last int64
+	for {
+		time.Sleep(time.Second)
+		s.pmu.Lock()
+		_, ok := s.processes["init"]
+		s.pmu.Unlock()
+		if !ok {
+			return
+		}
+		n, ok := readOOMCount(path)
+		if !ok || n <= last {
+			continue
+		}
+		last = n
+		s.publish(Event{Kind: EventOOM, ContainerID: id})
+	}
+}
+
+// readOOMCount extracts the "oom" counter (cgroup v2, memory.events) or
+// the "oom_kill" counter (cgroup v1, memory.oom_control) from a cgroup
+// file. ok is false if the file could not be read or neither key was
+// found.
+func readOOMCount(path string) (n int64, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var key string
+		var val int64
+		if _, err := fmt.Sscanf(scanner.Text(), "%s %d", &key, &val); err != nil {
+			continue
+		}
+		if key == "oom" || key == "oom_kill" {
+			return val, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,44 @@
+package shim
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writeOOMFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "oom")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestReadOOMCountV2(t *testing.T) {
+	path := writeOOMFile(t, "low 0\nhigh 0\nmax 0\noom 3\noom_kill 3\n")
+	n, ok := readOOMCount(path)
+	if !ok {
+		t.Fatal("readOOMCount: ok = false, want true")
+	}
+	if n != 3 {
+		t.Fatalf("n = %d, want 3", n)
+	}
+}
+
+func TestReadOOMCountV1(t *testing.T) {
+	path := writeOOMFile(t, "oom_kill_disable 0\nunder_oom 0\noom_kill 2\n")
+	n, ok := readOOMCount(path)
+	if !ok {
+		t.Fatal("readOOMCount: ok = false, want true")
+	}
+	if n != 2 {
+		t.Fatalf("n = %d, want 2", n)
+	}
+}
+
+func TestReadOOMCountMissingFile(t *testing.T) {
+	if _, ok := readOOMCount(filepath.Join(t.TempDir(), "missing")); ok {
+		t.Fatal("readOOMCount: ok = true for a missing file, want false")
+	}
+}
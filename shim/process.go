@@ -0,0 +1,76 @@
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/containerkit"
+)
+
+// ErrShimExited is returned when a forked shim child exits before it
+// manages to create the container it was asked to.
+var ErrShimExited = errors.New("shim: shim exited before creating the container")
+
+// process tracks a container's init process. It is populated two ways:
+// newProcess, for the legacy Create/Start path, which forks a child shim
+// binary and tracks that child's own exit; and newContainerProcess, for
+// the shim v2 task API's CreateTask/Restore, which drives the OCI runtime
+// driver directly and has no child of its own to track. Either way, done
+// is closed and success set once the process is known to have exited, so
+// the rest of this package (Start, Wait, Delete, State) can treat both
+// the same way.
+type process struct {
+	dir     string
+	cmd     *exec.Cmd
+	pid     int
+	done    chan struct{}
+	success bool
+}
+
+// newProcess lays out dir for a container about to be created by forking
+// cmd (a child shim binary) and writes its process.json from c's spec so
+// that child can read back what to run. cmd is not started here; the
+// caller starts it and then calls checkExited/waitForCreate.
+func newProcess(dir string, noPivotRoot bool, checkpoint string, c *containerkit.Container, cmd *exec.Cmd) (*process, error) {
+	if err := os.MkdirAll(dir, 0711); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, "process.json"))
+	if err != nil {
+		return nil, err
+	}
+	err = json.NewEncoder(f).Encode(c.Spec())
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &process{dir: dir, cmd: cmd, done: make(chan struct{})}, nil
+}
+
+// newContainerProcess wraps pid, the init process of a container already
+// created and started via the OCI runtime driver, the same way newProcess
+// wraps a freshly forked shim child, so the rest of this package can
+// address it identically. The caller still has to start a goroutine (see
+// watchContainerExit) to close done once the container actually exits.
+func newContainerProcess(dir string, pid int) *process {
+	return &process{dir: dir, pid: pid, done: make(chan struct{})}
+}
+
+// checkExited waits for the forked shim child to exit, then records
+// whether it exited cleanly and closes done so Start/Wait can unblock.
+func (p *process) checkExited() {
+	err := p.cmd.Wait()
+	p.success = err == nil
+	close(p.done)
+}
+
+// waitForCreate blocks until the forked shim has written its pid file,
+// signaling the container was created, or until timeout elapses.
+func (p *process) waitForCreate(timeout time.Duration) error {
+	_, err := waitForPidFile(filepath.Join(p.dir, "pid"), timeout)
+	return err
+}
@@ -37,12 +37,14 @@ var (
 )
 
 type Opts struct {
-	Name        string
-	RuntimeName string
-	RuntimeArgs []string
-	NoPivotRoot bool
-	Root        string
-	Timeout     time.Duration
+	Name          string
+	RuntimeName   string
+	RuntimeArgs   []string
+	RuntimeConfig map[string]string
+	NoPivotRoot   bool
+	Root          string
+	Bundle        string
+	Timeout       time.Duration
 }
 
 func New(opts Opts) (*Shim, error) {
@@ -50,8 +52,9 @@ func New(opts Opts) (*Shim, error) {
 		return nil, err
 	}
 	r, err := oci.New(oci.Opts{
-		Name: opts.RuntimeName,
-		Args: opts.RuntimeArgs,
+		Name:          opts.RuntimeName,
+		Args:          opts.RuntimeArgs,
+		RuntimeConfig: opts.RuntimeConfig,
 	})
 	if err != nil {
 		return nil, err
@@ -59,9 +62,14 @@ func New(opts Opts) (*Shim, error) {
 	s := &Shim{
 		root:      opts.Root,
 		name:      opts.Name,
+		bundle:    opts.Bundle,
 		timeout:   opts.Timeout,
 		runtime:   r,
 		processes: make(map[string]*process),
+		execs:     make(map[string]*execProcess),
+	}
+	if err := s.initEvents(); err != nil {
+		return nil, err
 	}
 	f, err := os.Create(filepath.Join(opts.Root, "state.json"))
 	if err != nil {
@@ -85,6 +93,12 @@ func Load(root string) (*Shim, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.root = root
+	s.processes = make(map[string]*process)
+	s.execs = make(map[string]*execProcess)
+	if err := s.initEvents(); err != nil {
+		return nil, err
+	}
 	// TODO: read processes into memory
 	return &s, nil
 }
@@ -104,8 +118,16 @@ type Shim struct {
 	runtime     *oci.OCIRuntime
 	pmu         sync.Mutex
 	processes   map[string]*process
+	execs       map[string]*execProcess
 	bundle      string
 	checkpoint  string
+	address     string
+	containerID string
+	cgroup      string
+
+	emu         sync.Mutex
+	eventsLog   *os.File
+	subscribers map[*subscriber]struct{}
 }
 
 type state struct {
@@ -115,22 +137,42 @@ type state struct {
 	Runtime string `json:"runtime"`
 	// OCI runtime args
 	RuntimeArgs []string `json:"runtimeArgs"`
+	// OCI runtime driver-specific settings
+	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
 	// Shim binary name
 	Name string `json:"shim"`
 	/// NoPivotRoot option
 	NoPivotRoot bool `json:"noPivotRoot"`
 	// Timeout for container start
 	Timeout time.Duration `json:"timeout"`
+	// Address is the ttrpc socket address the shim v2 task service, if
+	// any, is listening on for this shim.
+	Address string `json:"address,omitempty"`
+	// ContainerID is the id of the container this shim was created for.
+	ContainerID string `json:"containerID,omitempty"`
+	// Checkpoint is the image path of the most recent CRIU checkpoint
+	// taken of (or restored into) this shim's container, if any.
+	Checkpoint string `json:"checkpoint,omitempty"`
+	// Cgroup is the container's cgroup path, discovered once from
+	// /proc/<pid>/cgroup when the init process is created (legacy Create),
+	// started (StartTask) or restored (Restore) so Stats and the oom
+	// watcher never need to re-scan /proc.
+	Cgroup string `json:"cgroup,omitempty"`
 }
 
 func (s *Shim) MarshalJSON() ([]byte, error) {
 	st := state{
-		Name:        s.name,
-		Bundle:      s.bundle,
-		Runtime:     s.runtime.Name(),
-		RuntimeArgs: s.runtime.Args(),
-		NoPivotRoot: s.noPivotRoot,
-		Timeout:     s.timeout,
+		Name:          s.name,
+		Bundle:        s.bundle,
+		Runtime:       s.runtime.Name(),
+		RuntimeArgs:   s.runtime.Args(),
+		RuntimeConfig: s.runtime.RuntimeConfig(),
+		NoPivotRoot:   s.noPivotRoot,
+		Timeout:       s.timeout,
+		Address:       s.address,
+		ContainerID:   s.containerID,
+		Checkpoint:    s.checkpoint,
+		Cgroup:        s.cgroup,
 	}
 	return json.Marshal(st)
 }
@@ -144,9 +186,14 @@ func (s *Shim) UnmarshalJSON(b []byte) error {
 	s.bundle = st.Bundle
 	s.timeout = st.Timeout
 	s.noPivotRoot = st.NoPivotRoot
+	s.address = st.Address
+	s.containerID = st.ContainerID
+	s.checkpoint = st.Checkpoint
+	s.cgroup = st.Cgroup
 	r, err := oci.New(oci.Opts{
-		Name: st.Runtime,
-		Args: st.RuntimeArgs,
+		Name:          st.Runtime,
+		Args:          st.RuntimeArgs,
+		RuntimeConfig: st.RuntimeConfig,
 	})
 	if err != nil {
 		return err
@@ -156,6 +203,7 @@ func (s *Shim) UnmarshalJSON(b []byte) error {
 }
 
 func (s *Shim) Create(c *containerkit.Container) (containerkit.ProcessDelegate, error) {
+	s.containerID = c.ID()
 	var (
 		root = filepath.Join(s.root, "init")
 		cmd  = s.command(c.ID(), c.Path(), s.runtime.Name())
@@ -174,7 +222,22 @@ func (s *Shim) Create(c *containerkit.Container) (containerkit.ProcessDelegate,
 	s.pmu.Lock()
 	s.processes["init"] = p
 	s.pmu.Unlock()
-	// ~TODO: oom and stats stuff here
+
+	pid, _ := readPidFile(filepath.Join(root, "pid"))
+	if path, err := discoverCgroupPath(pid); err == nil {
+		s.cgroup = path
+		_ = s.saveState()
+	}
+	s.publish(Event{Kind: EventCreate, ContainerID: c.ID(), Pid: pid})
+	go func() {
+		<-p.done
+		status := uint32(1)
+		if p.success {
+			status = 0
+		}
+		s.publish(Event{Kind: EventExit, ContainerID: c.ID(), ExitStatus: status, ExitedAt: time.Now()})
+	}()
+	go s.watchOOM(c.ID(), s.cgroupOrFallback(c.ID()))
 	return p, nil
 }
 
@@ -212,9 +275,46 @@ func (s *Shim) Start(c *containerkit.Container) error {
 			return err
 		}
 	}
+	pid, _ := readPidFile(filepath.Join(s.root, "init", "pid"))
+	s.publish(Event{Kind: EventStart, ContainerID: c.ID(), Pid: pid})
 	return nil
 }
 
+// Bundle returns the path to the OCI bundle the shim was created with.
+func (s *Shim) Bundle() string {
+	return s.bundle
+}
+
+// Root returns the directory the shim persists its runtime state under.
+func (s *Shim) Root() string {
+	return s.root
+}
+
+// Address returns the ttrpc socket address the shim v2 task service is
+// listening on, if one has been set via SetAddress.
+func (s *Shim) Address() string {
+	return s.address
+}
+
+// SetAddress records the ttrpc socket address the shim v2 task service is
+// listening on and persists it to state.json so Load can reattach to it
+// after a daemon restart.
+func (s *Shim) SetAddress(address string) error {
+	s.address = address
+	return s.saveState()
+}
+
+// saveState (re)writes state.json from the Shim's current in-memory
+// fields, the same encoding New performs when it first creates the file.
+func (s *Shim) saveState() error {
+	f, err := os.Create(filepath.Join(s.root, "state.json"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(s)
+}
+
 func (s *Shim) getContainerInit(c *containerkit.Container) (*process, error) {
 	s.pmu.Lock()
 	p, ok := s.processes["init"]
@@ -0,0 +1,209 @@
+package shim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containerd/cgroups"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Stats is a stable, driver-independent snapshot of a container's resource
+// usage, read from its cgroup.
+type Stats struct {
+	CPU    CPUStats
+	Memory MemoryStats
+	Pids   PidsStats
+	Blkio  []BlkioEntry
+}
+
+// CPUStats reports cpuacct usage and CFS bandwidth throttling.
+type CPUStats struct {
+	Usage            uint64
+	ThrottledPeriods uint64
+	ThrottledTime    uint64
+}
+
+// MemoryStats reports memory cgroup usage. OOMEvents is the same counter
+// the oom watcher in events.go polls.
+type MemoryStats struct {
+	Usage     uint64
+	Max       uint64
+	Cache     uint64
+	RSS       uint64
+	OOMEvents uint64
+}
+
+// PidsStats reports the pids cgroup's task count and limit.
+type PidsStats struct {
+	Current uint64
+	Limit   uint64
+}
+
+// BlkioEntry is a single per-device blkio counter, as reported by
+// blkio.throttle.io_service_bytes_recursive.
+type BlkioEntry struct {
+	Device string
+	Op     string
+	Value  uint64
+}
+
+// discoverCgroupPath parses /proc/<pid>/cgroup to find the path of pid's
+// cgroup, preferring the memory controller's entry on a v1 hierarchy and
+// falling back to the single unified entry on a v2 (cgroup2) one.
+func discoverCgroupPath(pid int) (string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return parseCgroup(f)
+}
+
+// parseCgroup reads /proc/<pid>/cgroup format lines from r and returns the
+// memory controller's path on a v1 hierarchy, or the single unified entry
+// on a v2 (cgroup2) one. It is split out from discoverCgroupPath so the
+// parsing logic can be exercised without a real /proc/<pid>/cgroup file.
+func parseCgroup(r io.Reader) (string, error) {
+	var fallback string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// format: hierarchy-ID:controller-list:path, e.g.
+		// "4:memory:/docker/<id>" (v1) or "0::/system.slice/..." (v2).
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fallback == "" {
+			fallback = fields[2]
+		}
+		for _, c := range strings.Split(fields[1], ",") {
+			if c == "memory" {
+				return fields[2], nil
+			}
+		}
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("shim: no cgroup entries")
+	}
+	return fallback, nil
+}
+
+// cgroupOrFallback returns the cgroup path discovered at create time, or,
+// for a Shim loaded from a state.json predating that discovery, the
+// synthetic path cgroupPath derives from id.
+func (s *Shim) cgroupOrFallback(id string) string {
+	if s.cgroup != "" {
+		return s.cgroup
+	}
+	return cgroupPath(id)
+}
+
+// loadCgroup loads the cgroups.Cgroup for this Shim's container, using the
+// unified hierarchy when the host runs in cgroups.Unified mode and the v1
+// hierarchy otherwise.
+func (s *Shim) loadCgroup(id string) (cgroups.Cgroup, error) {
+	path := s.cgroupOrFallback(id)
+	if cgroups.Mode() == cgroups.Unified {
+		return cgroups.LoadV2(cgroups.StaticPath(path))
+	}
+	return cgroups.Load(cgroups.V1, cgroups.StaticPath(path))
+}
+
+// Stats reads id's cgroup and returns a point in time snapshot of its CPU,
+// memory, pids and blkio usage.
+func (s *Shim) Stats(id string) (*Stats, error) {
+	cg, err := s.loadCgroup(id)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := cg.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Stats{}
+	if metrics.CPU != nil {
+		if metrics.CPU.Usage != nil {
+			st.CPU.Usage = metrics.CPU.Usage.Total
+		}
+		if metrics.CPU.Throttling != nil {
+			st.CPU.ThrottledPeriods = metrics.CPU.Throttling.ThrottledPeriods
+			st.CPU.ThrottledTime = metrics.CPU.Throttling.ThrottledTime
+		}
+	}
+	if metrics.Memory != nil {
+		st.Memory.Usage = metrics.Memory.Usage.GetUsage()
+		st.Memory.Max = metrics.Memory.Usage.GetLimit()
+		st.Memory.Cache = metrics.Memory.Cache
+		st.Memory.RSS = metrics.Memory.RSS
+	}
+	if metrics.Pids != nil {
+		st.Pids.Current = metrics.Pids.Current
+		st.Pids.Limit = metrics.Pids.Limit
+	}
+	if metrics.Blkio != nil {
+		for _, e := range metrics.Blkio.IoServiceBytesRecursive {
+			st.Blkio = append(st.Blkio, BlkioEntry{
+				Device: fmt.Sprintf("%d:%d", e.Major, e.Minor),
+				Op:     e.Op,
+				Value:  e.Value,
+			})
+		}
+	}
+	if n, ok := readOOMCount(oomControlPath(s.cgroupOrFallback(id))); ok {
+		st.Memory.OOMEvents = uint64(n)
+	}
+	return st, nil
+}
+
+// StatsStream returns a Stats snapshot on the returned channel every
+// interval, until ctx is canceled.
+func (s *Shim) StatsStream(ctx context.Context, id string, interval time.Duration) <-chan *Stats {
+	ch := make(chan *Stats, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				st, err := s.Stats(id)
+				if err != nil {
+					continue
+				}
+				select {
+				case ch <- st:
+				default:
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// Update marshals resources to JSON and invokes the OCI runtime's `update
+// --resources -` subcommand on id, reading the new limits from stdin the
+// way every OCI runtime's update subcommand expects.
+func (s *Shim) Update(id string, resources *specs.LinuxResources) error {
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return err
+	}
+	cmd := s.runtime.Command("update", "--resources", "-", id)
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %q", err, out)
+	}
+	return nil
+}
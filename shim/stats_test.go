@@ -0,0 +1,38 @@
+package shim
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCgroupV1PrefersMemoryController(t *testing.T) {
+	r := strings.NewReader(strings.Join([]string{
+		"11:devices:/docker/other",
+		"4:memory:/docker/abc",
+		"1:name=systemd:/docker/abc",
+	}, "\n") + "\n")
+	path, err := parseCgroup(r)
+	if err != nil {
+		t.Fatalf("parseCgroup: %v", err)
+	}
+	if path != "/docker/abc" {
+		t.Fatalf("path = %q, want %q", path, "/docker/abc")
+	}
+}
+
+func TestParseCgroupV2FallsBackToUnifiedEntry(t *testing.T) {
+	r := strings.NewReader("0::/system.slice/docker-abc.scope\n")
+	path, err := parseCgroup(r)
+	if err != nil {
+		t.Fatalf("parseCgroup: %v", err)
+	}
+	if path != "/system.slice/docker-abc.scope" {
+		t.Fatalf("path = %q, want %q", path, "/system.slice/docker-abc.scope")
+	}
+}
+
+func TestParseCgroupNoEntries(t *testing.T) {
+	if _, err := parseCgroup(strings.NewReader("")); err == nil {
+		t.Fatal("parseCgroup: expected an error for empty input")
+	}
+}
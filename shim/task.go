@@ -0,0 +1,499 @@
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/docker/containerd/oci/driver"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// control fifo opcodes, matching the protocol the shim binary's control
+// pipe already speaks for the init process (see the layout documented at
+// the top of this package).
+const (
+	ctlPause  = 1
+	ctlResume = 2
+	ctlResize = 3
+)
+
+// TaskIO describes the stdio fifos a process should be wired up to. It
+// mirrors the stdio triple used throughout the shim v2 task API so callers
+// outside this package (namely shim/v2) never need to reach into process
+// internals to plumb IO.
+type TaskIO struct {
+	Stdin    string
+	Stdout   string
+	Stderr   string
+	Terminal bool
+}
+
+// TaskState is a point in time snapshot of the init process or an exec,
+// returned by (*Shim).State.
+type TaskState struct {
+	ID       string
+	Bundle   string
+	Pid      int
+	Status   string
+	Stdin    string
+	Stdout   string
+	Stderr   string
+	Terminal bool
+}
+
+// execProcess tracks an additional process started inside the shim's
+// container via Exec. Each exec gets its own directory under the shim's
+// root holding its process.json and pid file. ResizePty looks for a
+// control fifo in that directory, as it does for the init process; this
+// package does not create that fifo itself.
+type execProcess struct {
+	id          string
+	containerID string
+	dir         string
+	pid         int
+	io          TaskIO
+	done        chan struct{}
+	status      int
+}
+
+// CreateTask creates id's init process from bundle via the OCI runtime
+// driver's create subcommand, the way the shim v2 task API's Create RPC
+// expects. Unlike the legacy Create in shim.go, which works by forking a
+// child shim binary, the v2 task service already is the shim, so there is
+// no child process to fork here. Like runc create itself, this leaves the
+// process stopped; StartTask actually runs it. It registers the result
+// under processes["init"] so every other method in this file (State,
+// Wait, Kill, Delete, ...) can address it via the empty execID exactly
+// like a container created through the legacy path.
+func (s *Shim) CreateTask(id, bundle string, terminal bool) (int, error) {
+	s.pmu.Lock()
+	_, exists := s.processes["init"]
+	s.pmu.Unlock()
+	if exists {
+		return -1, fmt.Errorf("shim: container %s already created", id)
+	}
+
+	s.containerID = id
+	s.bundle = bundle
+
+	dir := filepath.Join(s.root, "init")
+	if err := os.MkdirAll(dir, 0711); err != nil {
+		return -1, err
+	}
+	pidPath := filepath.Join(dir, "pid")
+	if err := s.runtime.Driver().Create(driver.CreateOpts{
+		ID:       id,
+		Bundle:   bundle,
+		PidFile:  pidPath,
+		Terminal: terminal,
+	}); err != nil {
+		return -1, err
+	}
+	pid, err := readPidFile(pidPath)
+	if err != nil {
+		return -1, err
+	}
+
+	p := newContainerProcess(dir, pid)
+	s.pmu.Lock()
+	s.processes["init"] = p
+	s.pmu.Unlock()
+	if err := s.saveState(); err != nil {
+		return -1, err
+	}
+	s.publish(Event{Kind: EventCreate, ContainerID: id, Pid: pid})
+	return pid, nil
+}
+
+// StartTask runs id's previously created init process via the OCI runtime
+// driver's start subcommand, then begins watching it for exit and OOM
+// kills the same way the legacy Create does.
+func (s *Shim) StartTask(id string) (int, error) {
+	s.pmu.Lock()
+	p, ok := s.processes["init"]
+	s.pmu.Unlock()
+	if !ok {
+		return -1, errInitProcessNotExist
+	}
+	if err := s.runtime.Driver().Start(id); err != nil {
+		return -1, err
+	}
+	if path, err := discoverCgroupPath(p.pid); err == nil {
+		s.cgroup = path
+		_ = s.saveState()
+	}
+	s.publish(Event{Kind: EventStart, ContainerID: id, Pid: p.pid})
+	go s.watchContainerExit(id, p)
+	go s.watchOOM(id, s.cgroupOrFallback(id))
+	return p.pid, nil
+}
+
+// watchContainerExit polls the OCI runtime driver for id's state until it
+// is no longer running, then closes p.done the same way checkExited does
+// for a process created by forking a child shim binary. There is no child
+// process of our own to cmd.Wait() on here, so, like watchOOM and
+// oci/driver/runsc.go's tailLog, this falls back to polling.
+func (s *Shim) watchContainerExit(id string, p *process) {
+	for {
+		time.Sleep(time.Second)
+		st, err := s.runtime.Driver().State(id)
+		if err != nil || st.Status == "stopped" {
+			p.success = err == nil
+			close(p.done)
+			status := uint32(1)
+			if p.success {
+				status = 0
+			}
+			s.publish(Event{
+				Kind:        EventExit,
+				ContainerID: id,
+				Pid:         p.pid,
+				ExitStatus:  status,
+				ExitedAt:    time.Now(),
+			})
+			return
+		}
+	}
+}
+
+// Exec starts a new process inside the shim's container using the OCI
+// runtime's exec subcommand, and tracks it under execID so it can later be
+// addressed by ResizePty, Kill, CloseIO, State and Wait. id is the OCI
+// runtime's container id, as passed to every invocation of the runtime
+// binary.
+func (s *Shim) Exec(id, execID string, spec *specs.Process, io TaskIO) (int, error) {
+	s.pmu.Lock()
+	_, ok := s.processes["init"]
+	s.pmu.Unlock()
+	if !ok {
+		return -1, errInitProcessNotExist
+	}
+
+	dir := filepath.Join(s.root, execID)
+	if err := os.Mkdir(dir, 0711); err != nil {
+		return -1, err
+	}
+	specPath := filepath.Join(dir, "process.json")
+	f, err := os.Create(specPath)
+	if err != nil {
+		return -1, err
+	}
+	err = json.NewEncoder(f).Encode(spec)
+	f.Close()
+	if err != nil {
+		return -1, err
+	}
+
+	pidPath := filepath.Join(dir, "pid")
+	// deliberately not "-d": runc exits with the exec'd process's own exit
+	// status when run in the foreground, which is the only way we can
+	// learn the real ExitStatus later, since we are never this process's
+	// parent and so can never wait4 it directly. --pid-file is still
+	// written as soon as the process starts, so the caller gets its pid
+	// back without waiting on the full command to finish.
+	args := []string{"exec", "--pid-file", pidPath, "--process", specPath}
+	if io.Terminal {
+		args = append(args, "--tty")
+	}
+	args = append(args, id)
+	cmd := s.runtime.Command(args...)
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+	pid, err := waitForPidFile(pidPath, 3*time.Second)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return -1, err
+	}
+	ep := &execProcess{
+		id:          execID,
+		containerID: id,
+		dir:         dir,
+		pid:         pid,
+		io:          io,
+		done:        make(chan struct{}),
+	}
+	s.pmu.Lock()
+	s.execs[execID] = ep
+	s.pmu.Unlock()
+	s.publish(Event{Kind: EventExecAdded, ContainerID: id, ProcessID: execID, Pid: pid})
+	go s.waitExec(cmd, ep)
+	return pid, nil
+}
+
+// waitForPidFile polls for path to appear, as runc writes its --pid-file
+// only once the exec'd process has actually started.
+func waitForPidFile(path string, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if pid, err := readPidFile(path); err == nil {
+			return pid, nil
+		}
+		if time.Now().After(deadline) {
+			return -1, fmt.Errorf("shim: timed out waiting for %s", path)
+		}
+		time.Sleep(15 * time.Millisecond)
+	}
+}
+
+// waitExec blocks until the runc exec invocation backing e exits, which
+// happens exactly when the process it execs does and with the same exit
+// status, then records that status and publishes the exit event.
+func (s *Shim) waitExec(cmd *exec.Cmd, e *execProcess) {
+	defer close(e.done)
+	err := cmd.Wait()
+	switch exitErr := err.(type) {
+	case nil:
+		e.status = 0
+	case *exec.ExitError:
+		e.status = exitErr.ExitCode()
+	default:
+		e.status = 1
+	}
+	s.publish(Event{
+		Kind:        EventExit,
+		ContainerID: e.containerID,
+		ProcessID:   e.id,
+		Pid:         e.pid,
+		ExitStatus:  uint32(e.status),
+		ExitedAt:    time.Now(),
+	})
+}
+
+// ResizePty resizes the terminal of the process identified by execID (the
+// empty string meaning the init process) by writing a resize message to
+// its control fifo.
+func (s *Shim) ResizePty(execID string, width, height uint32) error {
+	ctl, err := s.controlFifo(execID)
+	if err != nil {
+		return err
+	}
+	defer ctl.Close()
+	_, err = fmt.Fprintf(ctl, "%d,%d,%d\n", ctlResize, width, height)
+	return err
+}
+
+// Pause freezes every process in the container's cgroup.
+func (s *Shim) Pause() error {
+	ctl, err := s.controlFifo("")
+	if err != nil {
+		return err
+	}
+	defer ctl.Close()
+	if _, err = fmt.Fprintf(ctl, "%d\n", ctlPause); err != nil {
+		return err
+	}
+	s.publish(Event{Kind: EventPaused, ContainerID: s.containerID})
+	return nil
+}
+
+// Resume thaws a previously paused container.
+func (s *Shim) Resume() error {
+	ctl, err := s.controlFifo("")
+	if err != nil {
+		return err
+	}
+	defer ctl.Close()
+	if _, err = fmt.Fprintf(ctl, "%d\n", ctlResume); err != nil {
+		return err
+	}
+	s.publish(Event{Kind: EventResumed, ContainerID: s.containerID})
+	return nil
+}
+
+func (s *Shim) controlFifo(execID string) (*os.File, error) {
+	dir := filepath.Join(s.root, "init")
+	if execID != "" {
+		s.pmu.Lock()
+		e, ok := s.execs[execID]
+		s.pmu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("shim: exec process %s not found", execID)
+		}
+		dir = e.dir
+	}
+	return os.OpenFile(filepath.Join(dir, "control"), os.O_WRONLY, 0)
+}
+
+// CloseIO closes the stdin of the process identified by execID.
+func (s *Shim) CloseIO(execID string) error {
+	path, err := s.stdinPath(execID)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Shim) stdinPath(execID string) (string, error) {
+	if execID == "" {
+		s.pmu.Lock()
+		_, ok := s.processes["init"]
+		s.pmu.Unlock()
+		if !ok {
+			return "", errInitProcessNotExist
+		}
+		return filepath.Join(s.root, "init", "stdin"), nil
+	}
+	s.pmu.Lock()
+	e, ok := s.execs[execID]
+	s.pmu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("shim: exec process %s not found", execID)
+	}
+	return e.io.Stdin, nil
+}
+
+// Kill sends signal to the process identified by execID. When execID is
+// empty the signal targets the container's init process; all, if set, asks
+// the OCI runtime to signal every process in the container. id is the OCI
+// runtime's container id.
+func (s *Shim) Kill(id, execID string, signal uint32, all bool) error {
+	if execID != "" {
+		pid, err := s.pidFor(execID)
+		if err != nil {
+			return err
+		}
+		return syscall.Kill(pid, syscall.Signal(signal))
+	}
+	return s.runtime.Driver().Kill(id, signal, all)
+}
+
+// Pids returns the pids of every process currently running in the
+// container, as reported by the OCI runtime. id is the OCI runtime's
+// container id.
+func (s *Shim) Pids(id string) ([]int, error) {
+	return s.runtime.Driver().Ps(id)
+}
+
+// State returns a snapshot of the process identified by execID.
+func (s *Shim) State(execID string) (*TaskState, error) {
+	if execID != "" {
+		s.pmu.Lock()
+		e, ok := s.execs[execID]
+		s.pmu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("shim: exec process %s not found", execID)
+		}
+		return &TaskState{
+			ID:       execID,
+			Bundle:   s.bundle,
+			Pid:      e.pid,
+			Status:   statusFor(e.done),
+			Stdin:    e.io.Stdin,
+			Stdout:   e.io.Stdout,
+			Stderr:   e.io.Stderr,
+			Terminal: e.io.Terminal,
+		}, nil
+	}
+	s.pmu.Lock()
+	p, ok := s.processes["init"]
+	s.pmu.Unlock()
+	if !ok {
+		return nil, errInitProcessNotExist
+	}
+	pid, err := readPidFile(filepath.Join(s.root, "init", "pid"))
+	if err != nil {
+		return nil, err
+	}
+	return &TaskState{
+		ID:     "init",
+		Bundle: s.bundle,
+		Pid:    pid,
+		Status: statusFor(p.done),
+	}, nil
+}
+
+// Wait blocks until the process identified by execID exits and returns its
+// exit status and the time it exited.
+func (s *Shim) Wait(execID string) (uint32, time.Time, error) {
+	if execID != "" {
+		s.pmu.Lock()
+		e, ok := s.execs[execID]
+		s.pmu.Unlock()
+		if !ok {
+			return 0, time.Time{}, fmt.Errorf("shim: exec process %s not found", execID)
+		}
+		<-e.done
+		return uint32(e.status), time.Now(), nil
+	}
+	s.pmu.Lock()
+	p, ok := s.processes["init"]
+	s.pmu.Unlock()
+	if !ok {
+		return 0, time.Time{}, errInitProcessNotExist
+	}
+	<-p.done
+	status := uint32(1)
+	if p.success {
+		status = 0
+	}
+	return status, time.Now(), nil
+}
+
+// Delete waits for the process identified by execID to exit, as Wait
+// does, then removes it from the shim's bookkeeping and returns its final
+// exit status.
+func (s *Shim) Delete(execID string) (uint32, time.Time, error) {
+	status, at, err := s.Wait(execID)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	s.pmu.Lock()
+	if execID != "" {
+		delete(s.execs, execID)
+	} else {
+		delete(s.processes, "init")
+	}
+	s.pmu.Unlock()
+	if execID == "" {
+		// the whole container is gone; release whatever background
+		// resources its driver started (e.g. runsc's log tailer).
+		s.runtime.Close()
+	}
+	return status, at, nil
+}
+
+func (s *Shim) pidFor(execID string) (int, error) {
+	if execID == "" {
+		return readPidFile(filepath.Join(s.root, "init", "pid"))
+	}
+	s.pmu.Lock()
+	e, ok := s.execs[execID]
+	s.pmu.Unlock()
+	if !ok {
+		return -1, fmt.Errorf("shim: exec process %s not found", execID)
+	}
+	return e.pid, nil
+}
+
+func statusFor(done chan struct{}) string {
+	select {
+	case <-done:
+		return "stopped"
+	default:
+		return "running"
+	}
+}
+
+func readPidFile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return -1, err
+	}
+	var pid int
+	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
+		return -1, err
+	}
+	return pid, nil
+}
@@ -0,0 +1,177 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/containerd/ttrpc"
+	"github.com/docker/containerd/shim"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	shimapi "github.com/containerd/containerd/runtime/v2/shim"
+	taskAPI "github.com/containerd/containerd/runtime/v2/task"
+)
+
+// Manager boots new shim v2 instances. A containerd-shim-<name>-v2 binary's
+// main() constructs one with NewManager and calls Start/Stop as directed by
+// containerd's shim loader, which is what actually implements the shim v2
+// boot protocol (printing the returned address to stdout, detaching into
+// the background, and so on) on top of the Manager interface.
+type Manager struct {
+	// name identifies the shim, e.g. "runc" or "runsc". It is used to pick
+	// the oci/driver.Driver and is recorded in state.json. It is also what
+	// Name reports back to containerd.
+	name string
+	// root is the base directory under which each container gets its own
+	// state directory, root/<id>.
+	root string
+	// publisher forwards lifecycle events back to containerd. main()
+	// builds it from whatever event sink containerd told it to use (the
+	// ttrpc address passed on the shim's command line) before handing it
+	// to NewManager, since Start's signature - fixed by shimapi.Manager -
+	// has no room for one.
+	publisher Publisher
+
+	mu      sync.Mutex
+	running map[string]*runningShim
+}
+
+// runningShim is what Start hands to a later Stop: the listener and ttrpc
+// server it bound for one container id, so Stop has something to close.
+type runningShim struct {
+	listener net.Listener
+	server   *ttrpc.Server
+}
+
+var _ shimapi.Manager = (*Manager)(nil)
+
+// NewManager returns a Manager that serves the named runtime driver and
+// publishes events through publisher.
+func NewManager(name string, root string, publisher Publisher) *Manager {
+	return &Manager{
+		name:      name,
+		root:      root,
+		publisher: publisher,
+		running:   make(map[string]*runningShim),
+	}
+}
+
+// Name identifies this shim to containerd, e.g. "runc" or "runsc".
+func (m *Manager) Name() string {
+	return m.name
+}
+
+// Start creates (or, if state.json already exists, loads) the shim for id,
+// using the current working directory as its bundle per the shim v2
+// convention that containerd execs the shim binary with cwd set to the
+// bundle, binds a ttrpc server serving the task API to an abstract unix
+// socket, and returns that socket's address.
+func (m *Manager) Start(ctx context.Context, id string, opts shimapi.StartOpts) (string, error) {
+	bundle, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("shim/v2: %w", err)
+	}
+	root := filepath.Join(m.root, id)
+
+	s, err := loadOrCreate(id, bundle, root, m.name)
+	if err != nil {
+		return "", err
+	}
+
+	address := socketAddress(root, id)
+	l, err := net.Listen("unix", "\x00"+address)
+	if err != nil {
+		return "", fmt.Errorf("shim/v2: listen on %s: %w", address, err)
+	}
+
+	server, err := ttrpc.NewServer()
+	if err != nil {
+		l.Close()
+		return "", err
+	}
+	taskAPI.RegisterTaskService(server, newService(id, s, m.publisher))
+	go server.Serve(ctx, l)
+	go m.forwardExits(ctx, id, s)
+
+	if err := s.SetAddress(address); err != nil {
+		l.Close()
+		return "", err
+	}
+
+	m.mu.Lock()
+	m.running[id] = &runningShim{listener: l, server: server}
+	m.mu.Unlock()
+
+	return address, nil
+}
+
+// forwardExits drains s's event stream for EventExit and republishes each
+// one as a containerd TaskExit event, so containerd can reconcile a task's
+// state (e.g. after a daemon restart) without an in-flight Wait call to
+// block on. It runs until ctx is done; Stop shuts down the ttrpc server
+// and its listener but does not cancel ctx, so Stop does not end it.
+func (m *Manager) forwardExits(ctx context.Context, id string, s *shim.Shim) {
+	if m.publisher == nil {
+		return
+	}
+	ch, cancel := s.Subscribe(ctx)
+	defer cancel()
+	for ev := range ch {
+		if ev.Kind != shim.EventExit {
+			continue
+		}
+		m.publisher.Publish(ctx, "/tasks/exit", &eventstypes.TaskExit{
+			ContainerID: id,
+			ID:          ev.ProcessID,
+			Pid:         uint32(ev.Pid),
+			ExitStatus:  ev.ExitStatus,
+			ExitedAt:    ev.ExitedAt,
+		})
+	}
+}
+
+// Stop shuts down the ttrpc server and listener Start bound for id. It
+// does not touch state.json so that a subsequent Start (after a containerd
+// restart) can still Load it.
+func (m *Manager) Stop(ctx context.Context, id string) (shimapi.StopStatus, error) {
+	m.mu.Lock()
+	r, ok := m.running[id]
+	delete(m.running, id)
+	m.mu.Unlock()
+	if !ok {
+		return shimapi.StopStatus{}, fmt.Errorf("shim/v2: %s is not running", id)
+	}
+
+	r.server.Shutdown(ctx)
+	r.listener.Close()
+	return shimapi.StopStatus{}, nil
+}
+
+// Info reports the runtime this Manager serves, for containerd's shim
+// diagnostics (`ctr runtime info`, `containerd shim info`).
+func (m *Manager) Info(ctx context.Context) (*shimapi.RuntimeInfo, error) {
+	return &shimapi.RuntimeInfo{Name: m.name}, nil
+}
+
+func loadOrCreate(id, bundle, root, name string) (*shim.Shim, error) {
+	if _, err := os.Stat(filepath.Join(root, "state.json")); err == nil {
+		return shim.Load(root)
+	}
+	return shim.New(shim.Opts{
+		Name:        name,
+		RuntimeName: name,
+		Root:        root,
+		Bundle:      bundle,
+	})
+}
+
+// socketAddress derives a stable abstract socket name for id so that
+// Connect/Load can recompute it without consulting state.json.
+func socketAddress(root, id string) string {
+	return strings.Join([]string{"containerd-shim", id, root}, "/")
+}
@@ -0,0 +1,224 @@
+// Package v2 adapts the in-process shim.Shim runtime to the containerd
+// shim v2 task API so that containerd-shim-<name>-v2 binaries built on top
+// of this module can be run directly by a modern containerd daemon over
+// ttrpc, instead of only through the legacy in-process Runtime interface.
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/docker/containerd/shim"
+	ptypes "github.com/gogo/protobuf/types"
+
+	taskAPI "github.com/containerd/containerd/runtime/v2/task"
+)
+
+// Publisher publishes lifecycle events back to containerd. It is satisfied
+// by the ttrpc events client containerd hands the shim on startup.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, event interface{}) error
+}
+
+// service implements taskAPI.TaskService on top of a *shim.Shim, translating
+// between the ttrpc wire types and the shim's own process API.
+type service struct {
+	mu sync.Mutex
+
+	id        string
+	shim      *shim.Shim
+	publisher Publisher
+}
+
+var _ taskAPI.TaskService = (*service)(nil)
+
+// newService wraps an already created *shim.Shim so it can be served over
+// ttrpc as a task API v2 service.
+func newService(id string, s *shim.Shim, publisher Publisher) *service {
+	return &service{
+		id:        id,
+		shim:      s,
+		publisher: publisher,
+	}
+}
+
+func (s *service) Create(ctx context.Context, r *taskAPI.CreateTaskRequest) (*taskAPI.CreateTaskResponse, error) {
+	pid, err := s.shim.CreateTask(s.id, r.Bundle, r.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "/tasks/create", &taskAPI.CreateTaskRequest{ID: s.id})
+	return &taskAPI.CreateTaskResponse{Pid: uint32(pid)}, nil
+}
+
+func (s *service) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.StartResponse, error) {
+	// an exec was already started in full by Exec below; Start just hands
+	// its pid back. Only the init task defers the actual runtime start
+	// (driver Start, cgroup discovery, exit/OOM watchers) to here.
+	if r.ExecID != "" {
+		state, err := s.shim.State(r.ExecID)
+		if err != nil {
+			return nil, err
+		}
+		s.publish(ctx, "/tasks/start", &taskAPI.StartRequest{ID: s.id, ExecID: r.ExecID})
+		return &taskAPI.StartResponse{Pid: uint32(state.Pid)}, nil
+	}
+	pid, err := s.shim.StartTask(s.id)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "/tasks/start", &taskAPI.StartRequest{ID: s.id})
+	return &taskAPI.StartResponse{Pid: uint32(pid)}, nil
+}
+
+func (s *service) Delete(ctx context.Context, r *taskAPI.DeleteRequest) (*taskAPI.DeleteResponse, error) {
+	status, at, err := s.shim.Delete(r.ExecID)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "/tasks/delete", &taskAPI.DeleteRequest{ID: s.id, ExecID: r.ExecID})
+	return &taskAPI.DeleteResponse{
+		ExitStatus: status,
+		ExitedAt:   at,
+	}, nil
+}
+
+func (s *service) Exec(ctx context.Context, r *taskAPI.ExecProcessRequest) (*ptypes.Empty, error) {
+	if _, err := s.shim.Exec(s.id, r.ExecID, r.Spec, shim.TaskIO{
+		Stdin:    r.Stdin,
+		Stdout:   r.Stdout,
+		Stderr:   r.Stderr,
+		Terminal: r.Terminal,
+	}); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "/tasks/exec-added", &taskAPI.ExecProcessRequest{ID: s.id, ExecID: r.ExecID})
+	return empty, nil
+}
+
+func (s *service) ResizePty(ctx context.Context, r *taskAPI.ResizePtyRequest) (*ptypes.Empty, error) {
+	if err := s.shim.ResizePty(r.ExecID, r.Width, r.Height); err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+func (s *service) State(ctx context.Context, r *taskAPI.StateRequest) (*taskAPI.StateResponse, error) {
+	st, err := s.shim.State(r.ExecID)
+	if err != nil {
+		return nil, err
+	}
+	return &taskAPI.StateResponse{
+		ID:       st.ID,
+		Bundle:   st.Bundle,
+		Pid:      uint32(st.Pid),
+		Status:   st.Status,
+		Stdin:    st.Stdin,
+		Stdout:   st.Stdout,
+		Stderr:   st.Stderr,
+		Terminal: st.Terminal,
+	}, nil
+}
+
+func (s *service) Pause(ctx context.Context, r *taskAPI.PauseRequest) (*ptypes.Empty, error) {
+	if err := s.shim.Pause(); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "/tasks/paused", &taskAPI.PauseRequest{ID: s.id})
+	return empty, nil
+}
+
+func (s *service) Resume(ctx context.Context, r *taskAPI.ResumeRequest) (*ptypes.Empty, error) {
+	if err := s.shim.Resume(); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "/tasks/resumed", &taskAPI.ResumeRequest{ID: s.id})
+	return empty, nil
+}
+
+func (s *service) Kill(ctx context.Context, r *taskAPI.KillRequest) (*ptypes.Empty, error) {
+	if err := s.shim.Kill(s.id, r.ExecID, r.Signal, r.All); err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+func (s *service) Pids(ctx context.Context, r *taskAPI.PidsRequest) (*taskAPI.PidsResponse, error) {
+	pids, err := s.shim.Pids(s.id)
+	if err != nil {
+		return nil, err
+	}
+	resp := &taskAPI.PidsResponse{}
+	for _, pid := range pids {
+		resp.Processes = append(resp.Processes, &taskAPI.ProcessInfo{Pid: uint32(pid)})
+	}
+	return resp, nil
+}
+
+func (s *service) CloseIO(ctx context.Context, r *taskAPI.CloseIORequest) (*ptypes.Empty, error) {
+	if err := s.shim.CloseIO(r.ExecID); err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+func (s *service) Checkpoint(ctx context.Context, r *taskAPI.CheckpointTaskRequest) (*ptypes.Empty, error) {
+	if err := s.shim.Checkpoint(s.id, shim.CheckpointOpts{ImagePath: r.Path}); err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+func (s *service) Connect(ctx context.Context, r *taskAPI.ConnectRequest) (*taskAPI.ConnectResponse, error) {
+	pid, err := s.shim.State("")
+	if err != nil {
+		return nil, err
+	}
+	return &taskAPI.ConnectResponse{ShimPid: uint32(pid.Pid), TaskPid: uint32(pid.Pid)}, nil
+}
+
+func (s *service) Shutdown(ctx context.Context, r *taskAPI.ShutdownRequest) (*ptypes.Empty, error) {
+	return empty, nil
+}
+
+func (s *service) Stats(ctx context.Context, r *taskAPI.StatsRequest) (*taskAPI.StatsResponse, error) {
+	st, err := s.shim.Stats(s.id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	return &taskAPI.StatsResponse{Stats: data}, nil
+}
+
+func (s *service) Update(ctx context.Context, r *taskAPI.UpdateTaskRequest) (*ptypes.Empty, error) {
+	if err := s.shim.Update(s.id, r.Resources); err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+func (s *service) Wait(ctx context.Context, r *taskAPI.WaitRequest) (*taskAPI.WaitResponse, error) {
+	status, at, err := s.shim.Wait(r.ExecID)
+	if err != nil {
+		return nil, err
+	}
+	return &taskAPI.WaitResponse{ExitStatus: status, ExitedAt: at}, nil
+}
+
+func (s *service) publish(ctx context.Context, topic string, event interface{}) {
+	if s.publisher == nil {
+		return
+	}
+	if err := s.publisher.Publish(ctx, topic, event); err != nil {
+		// the task is the source of truth for its own state; a failed
+		// publish just means containerd's view is stale until the next
+		// successful event or a State/Wait call reconciles it.
+		return
+	}
+}
+
+var empty = &ptypes.Empty{}